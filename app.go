@@ -1,33 +1,50 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"io/ioutil"
 	"os"
-	"strings"
-	"sync"
-	"text/template"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
-	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/azureblob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/s3blob"
-	"gocloud.dev/gcerrors"
 )
 
+// partialsPrefix namespaces the partials store from the regular templates
+// store, both as the blob key prefix (via namespacedStore) and as the
+// depIndex/cache key prefix (see compose.go), so a partial and a
+// top-level template can share a bare name without colliding.
+const partialsPrefix = "partials/"
+
 var (
-	blobBucketURL      = os.Getenv("TEMPLATE_BLOB_BUCKET_URL")
-	blobPrefix         = os.Getenv("TEMPLATE_BLOB_PREFIX")
-	templateCache      = cache.New(5*time.Minute, 10*time.Minute)
-	templateCacheMutex = new(sync.Mutex)
+	blobBucketURL = os.Getenv("TEMPLATE_BLOB_BUCKET_URL")
+	blobPrefix    = os.Getenv("TEMPLATE_BLOB_PREFIX")
+	storeBackend  = os.Getenv("TEMPLATE_STORE_BACKEND")
+	rpcAddr       = os.Getenv("TEMPLATE_RPC_ADDR")
+	rpcListenAddr = os.Getenv("TEMPLATE_RPC_LISTEN_ADDR")
+
+	store         TemplateStore
+	partialsStore TemplateStore
 )
 
 func main() {
+	ctx := context.Background()
+
+	var err error
+	store, err = newTemplateStore(ctx)
+	if err != nil {
+		panic(err)
+	}
+	partialsStore = &namespacedStore{inner: store, prefix: partialsPrefix}
+
+	if rpcListenAddr != "" {
+		go func() {
+			if err := ServeTemplateStoreRPC(rpcListenAddr, store); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
 	r := gin.Default()
 
 	r.PUT("/templates/:templatename", func(c *gin.Context) {
@@ -37,16 +54,17 @@ func main() {
 		}
 
 		templateName := c.Param("templatename")
-
 		bodyStr := string(body)
-		// validation
-		_, err = template.New(templateName).Parse(bodyStr)
+
+		// validation: the body must parse, and every {{template}} it
+		// references (directly or transitively) must resolve.
+		_, err = resolveTemplate(c, templateName, templateName, bodyStr)
 		if err != nil {
 			c.AbortWithError(400, err)
 			return
 		}
 
-		err = saveTemplate(c, templateName, bodyStr)
+		err = saveTemplate(c, store, templateName, templateName, bodyStr)
 		if err != nil {
 			c.AbortWithError(500, err)
 			return
@@ -58,7 +76,7 @@ func main() {
 
 		tmpl, err := getTemplateWithCaching(c, templateName)
 		if err != nil {
-			c.AbortWithError(400, err)
+			c.AbortWithError(500, err)
 			return
 		}
 		if tmpl == nil {
@@ -69,10 +87,14 @@ func main() {
 		c.String(200, tmpl.Root.String())
 	})
 
+	r.HEAD("/templates/:templatename", headTemplateHandler)
+
+	r.GET("/templates", listTemplatesHandler)
+
 	r.DELETE("/templates/:templatename", func(c *gin.Context) {
 		templateName := c.Param("templatename")
 
-		err := deleteTemplate(c, templateName)
+		err := deleteTemplate(c, store, templateName, templateName)
 		if err != nil {
 			c.AbortWithError(400, err)
 			return
@@ -81,129 +103,85 @@ func main() {
 		c.Status(204)
 	})
 
-	r.GET("/templates/:templatename/apply", func(c *gin.Context) {
-		templateName := c.Param("templatename")
-		params := map[string]interface{}{}
-		for k, v := range c.Request.URL.Query() {
-			if strings.HasSuffix(k, "[]") {
-				// treat as array
-				params[strings.TrimSuffix(k, "[]")] = v
-				continue
-			}
-			params[k] = v[0]
+	r.PUT("/partials/:partialname", func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil {
+			c.AbortWithError(400, err)
 		}
 
-		tmpl, err := getTemplateWithCaching(c, templateName)
+		partialName := c.Param("partialname")
+		bodyStr := string(body)
+
+		_, err = resolveTemplate(c, partialsPrefix+partialName, partialName, bodyStr)
 		if err != nil {
-			c.AbortWithError(500, err)
+			c.AbortWithError(400, err)
 			return
 		}
-		if tmpl == nil {
-			c.AbortWithStatus(404)
+
+		err = saveTemplate(c, partialsStore, partialsPrefix+partialName, partialName, bodyStr)
+		if err != nil {
+			c.AbortWithError(500, err)
 			return
 		}
-		fmt.Println(params)
+	})
 
-		buf := bytes.Buffer{}
-		err = tmpl.Execute(&buf, params)
+	r.DELETE("/partials/:partialname", func(c *gin.Context) {
+		partialName := c.Param("partialname")
+
+		err := deleteTemplate(c, partialsStore, partialsPrefix+partialName, partialName)
 		if err != nil {
 			c.AbortWithError(400, err)
 			return
 		}
 
-		c.String(200, buf.String())
+		c.Status(204)
 	})
 
-	r.Run()
-}
-
-func openPrefixedBucket(c context.Context) (*blob.Bucket, error) {
-	bucket, err := blob.OpenBucket(c, blobBucketURL)
-	if err != nil {
-		return nil, err
-	}
-
-	return blob.PrefixedBucket(bucket, blobPrefix), nil
-}
-
-func saveTemplate(ctx context.Context, name string, text string) error {
-	bucket, err := openPrefixedBucket(ctx)
-	if err != nil {
-		return err
-	}
-
-	blobWriter, err := bucket.NewWriter(ctx, name, nil)
-	if err != nil {
-		return err
-	}
-
-	_, err = blobWriter.Write([]byte(text))
-	if err != nil {
-		blobWriter.Close()
-		return err
-	}
+	r.GET("/templates/:templatename/apply", func(c *gin.Context) {
+		templateName := c.Param("templatename")
 
-	err = blobWriter.Close()
-	if err != nil {
-		return err
-	}
+		params, err := paramsFromQuery(c.Request.URL.Query(), c.Query("types") == "json")
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
 
-	templateCache.Delete(name)
-	return nil
-}
+		applyTemplate(c, templateName, params)
+	})
 
-func getTemplateWithCaching(ctx context.Context, name string) (*template.Template, error) {
-	tmpl, found := templateCache.Get(name)
-	if found {
-		return tmpl.(*template.Template), nil
-	}
+	r.POST("/templates/:templatename/apply", func(c *gin.Context) {
+		templateName := c.Param("templatename")
 
-	templateCacheMutex.Lock()
-	defer templateCacheMutex.Unlock()
+		params, err := paramsFromBody(c)
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
 
-	tmpl, found = templateCache.Get(name)
-	if found {
-		return tmpl.(*template.Template), nil
-	}
+		applyTemplate(c, templateName, params)
+	})
 
-	tmpl, err := getTemplate(ctx, name)
-	if err != nil {
-		return nil, err
-	}
-	templateCache.SetDefault(name, tmpl)
-	return tmpl.(*template.Template), nil
+	r.Run()
 }
 
-func getTemplate(ctx context.Context, name string) (*template.Template, error) {
-	bucket, err := openPrefixedBucket(ctx)
+// saveTemplate writes name (plain for a template, partialsPrefix-prefixed
+// for a partial) to targetStore and invalidates its depIndex/cache entry
+// by cacheKey, which must be namespaced the same way.
+func saveTemplate(ctx context.Context, targetStore TemplateStore, cacheKey, name string, text string) error {
+	err := targetStore.Put(ctx, name, []byte(text))
 	if err != nil {
-		return nil, err
-	}
-
-	blobReader, err := bucket.NewReader(ctx, name, nil)
-	if gcerrors.Code(err) == gcerrors.NotFound {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	defer blobReader.Close()
-	data, err := ioutil.ReadAll(blobReader)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return template.New(name).Parse(string(data))
+	depIndex.invalidate(cacheKey)
+	return nil
 }
 
-func deleteTemplate(ctx context.Context, name string) error {
-	bucket, err := openPrefixedBucket(ctx)
-	if err != nil {
-		return err
+func deleteTemplate(ctx context.Context, targetStore TemplateStore, cacheKey, name string) error {
+	err := targetStore.Delete(ctx, name)
+	if err == ErrNotFound {
+		err = nil
 	}
-
-	bucket.Delete(ctx, name)
-	templateCache.Delete(name)
+	depIndex.invalidate(cacheKey)
 	return err
 }
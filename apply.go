@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// applyTemplate fetches templateName and executes it with params, shared
+// by the GET and POST /apply routes.
+func applyTemplate(c *gin.Context, templateName string, params map[string]interface{}) {
+	tmpl, err := getTemplateWithCaching(c, templateName)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	if tmpl == nil {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	buf := bytes.Buffer{}
+	err = tmpl.Execute(&buf, params)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	c.String(200, buf.String())
+}
+
+// paramsFromQuery builds template params from URL query values: a "[]"
+// suffix on a key is treated as an array, and everything else is the first
+// value as a plain string. If typesJSON is true, each value is instead
+// parsed as JSON.
+func paramsFromQuery(query url.Values, typesJSON bool) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+
+	for k, v := range query {
+		name := k
+		array := strings.HasSuffix(k, "[]")
+		if array {
+			name = strings.TrimSuffix(k, "[]")
+		}
+
+		if !typesJSON {
+			if array {
+				params[name] = v
+			} else {
+				params[name] = v[0]
+			}
+			continue
+		}
+
+		if array {
+			values := make([]interface{}, len(v))
+			for i, raw := range v {
+				parsed, err := parseJSONValue(raw)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = parsed
+			}
+			params[name] = values
+			continue
+		}
+
+		parsed, err := parseJSONValue(v[0])
+		if err != nil {
+			return nil, err
+		}
+		params[name] = parsed
+	}
+
+	return params, nil
+}
+
+func parseJSONValue(raw string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// paramsFromBody builds template params from a POST /apply body.
+// Content-Type picks the decoding: application/json and application/yaml
+// unmarshal directly into the params map; application/x-www-form-urlencoded
+// is parsed the same way as a GET query string.
+func paramsFromBody(c *gin.Context) (map[string]interface{}, error) {
+	contentType := strings.TrimSpace(strings.SplitN(c.GetHeader("Content-Type"), ";", 2)[0])
+
+	switch contentType {
+	case "", "application/json":
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+
+		params := map[string]interface{}{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &params); err != nil {
+				return nil, err
+			}
+		}
+		return params, nil
+
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+
+		params := map[string]interface{}{}
+		if len(body) > 0 {
+			if err := yaml.Unmarshal(body, &params); err != nil {
+				return nil, err
+			}
+		}
+		return params, nil
+
+	case "application/x-www-form-urlencoded":
+		if err := c.Request.ParseForm(); err != nil {
+			return nil, err
+		}
+		return paramsFromQuery(c.Request.PostForm, c.Query("types") == "json")
+
+	default:
+		return nil, errors.New("unsupported Content-Type: " + contentType)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// BlobTemplateStore implements TemplateStore directly on top of a
+// gocloud.dev blob.Bucket.
+type BlobTemplateStore struct {
+	bucket *blob.Bucket
+}
+
+// NewBlobTemplateStore opens bucketURL and scopes every operation under
+// prefix.
+func NewBlobTemplateStore(ctx context.Context, bucketURL, prefix string) (*BlobTemplateStore, error) {
+	bucket, err := blob.OpenBucket(ctx, bucketURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobTemplateStore{bucket: blob.PrefixedBucket(bucket, prefix)}, nil
+}
+
+func (s *BlobTemplateStore) Put(ctx context.Context, name string, data []byte) error {
+	preETag := ""
+	hadPre := false
+	if pre, err := s.Head(ctx, name); err == nil {
+		preETag = pre.ETag
+		hadPre = true
+	}
+
+	return withRetry(ctx, func(attempt int) error {
+		// A retry's first-attempt outcome is unknown, so refuse to overwrite
+		// if name changed since we observed preETag.
+		if attempt > 1 {
+			cur, err := s.Head(ctx, name)
+			switch {
+			case err != nil && err != ErrNotFound:
+				return err
+			case err == nil && (!hadPre || cur.ETag != preETag):
+				return fmt.Errorf("precondition failed: %q changed since the first write attempt", name)
+			case err == ErrNotFound && hadPre:
+				return fmt.Errorf("precondition failed: %q was deleted since the first write attempt", name)
+			}
+		}
+
+		blobWriter, err := s.bucket.NewWriter(ctx, name, nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = blobWriter.Write(data)
+		if err != nil {
+			blobWriter.Close()
+			return err
+		}
+
+		return blobWriter.Close()
+	})
+}
+
+// blobETag derives the ETag Get, Head and List agree on for the same
+// object: the backend's own ETag, then hex-encoded MD5, then ModTime.
+// List only has MD5/ModTime available per entry, so it must go through
+// this same helper to produce an ETag comparable to Get/Head's.
+func blobETag(etag string, md5 []byte, modTime time.Time) string {
+	if etag != "" {
+		return etag
+	}
+	if len(md5) > 0 {
+		return hex.EncodeToString(md5)
+	}
+	return modTime.String()
+}
+
+func (s *BlobTemplateStore) Get(ctx context.Context, name string) (*TemplateEntry, error) {
+	var entry *TemplateEntry
+
+	err := withRetry(ctx, func(attempt int) error {
+		attrs, err := s.bucket.Attributes(ctx, name)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		blobReader, err := s.bucket.NewReader(ctx, name, nil)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		defer blobReader.Close()
+
+		data, err := ioutil.ReadAll(blobReader)
+		if err != nil {
+			return err
+		}
+
+		entry = &TemplateEntry{Name: name, Data: data, ETag: blobETag(attrs.ETag, attrs.MD5, attrs.ModTime)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *BlobTemplateStore) Head(ctx context.Context, name string) (*TemplateAttributes, error) {
+	var out *TemplateAttributes
+
+	err := withRetry(ctx, func(attempt int) error {
+		attrs, err := s.bucket.Attributes(ctx, name)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		out = &TemplateAttributes{ETag: blobETag(attrs.ETag, attrs.MD5, attrs.ModTime), Size: attrs.Size, ModTime: attrs.ModTime}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *BlobTemplateStore) Delete(ctx context.Context, name string) error {
+	return withRetry(ctx, func(attempt int) error {
+		err := s.bucket.Delete(ctx, name)
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+func (s *BlobTemplateStore) List(ctx context.Context, prefix string, limit int, pageToken string) (*TemplateListPage, error) {
+	var token []byte
+	if pageToken != "" {
+		decoded, err := base64.URLEncoding.DecodeString(pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pageToken: %w", err)
+		}
+		token = decoded
+	}
+
+	var page *TemplateListPage
+
+	err := withRetry(ctx, func(attempt int) error {
+		objs, nextToken, err := s.bucket.ListPage(ctx, token, limit, &blob.ListOptions{Prefix: prefix})
+		if err != nil {
+			return err
+		}
+
+		entries := make([]TemplateListEntry, len(objs))
+		for i, obj := range objs {
+			entries[i] = TemplateListEntry{Name: obj.Key, Size: obj.Size, ModTime: obj.ModTime, ETag: blobETag("", obj.MD5, obj.ModTime)}
+		}
+
+		nextPageToken := ""
+		if len(nextToken) > 0 {
+			nextPageToken = base64.URLEncoding.EncodeToString(nextToken)
+		}
+
+		page = &TemplateListPage{Entries: entries, NextPageToken: nextPageToken}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
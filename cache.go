@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"text/template"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+var (
+	parsedCacheMaxEntries = envInt("TEMPLATE_CACHE_PARSED_MAX_ENTRIES", 1000)
+	parsedCacheTTL        = envDuration("TEMPLATE_CACHE_PARSED_TTL", 5*time.Minute)
+	rawCacheMaxBytes      = envInt("TEMPLATE_CACHE_RAW_MAX_BYTES", 64*1024*1024)
+
+	parsedCache = lru.NewLRU[string, parsedCacheEntry](parsedCacheMaxEntries, nil, parsedCacheTTL)
+	rawCache    = newRawByteCache(rawCacheMaxBytes)
+)
+
+// parsedCacheEntry pairs a resolved template bundle with the ETag it was
+// parsed from. depIndex.invalidate (see depindex.go) is the only
+// invalidation path: it evicts an entry by name directly, so there's
+// nothing here to re-check on a cache hit.
+type parsedCacheEntry struct {
+	tmpl *template.Template
+	etag string
+}
+
+// getTemplateWithCaching checks the parsed-template LRU first; on a miss it
+// HEADs the store for the current ETag and looks up the raw source cache by
+// name+ETag before falling back to a full fetch.
+func getTemplateWithCaching(ctx context.Context, name string) (*template.Template, error) {
+	if entry, ok := parsedCache.Get(name); ok {
+		return entry.tmpl, nil
+	}
+
+	attrs, err := store.Head(ctx, name)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	etag := attrs.ETag
+
+	data, ok := rawCache.get(name, etag)
+	if !ok {
+		entry, err := store.Get(ctx, name)
+		if err == ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data = entry.Data
+		etag = entry.ETag
+		rawCache.set(name, etag, data)
+	}
+
+	tmpl, err := resolveTemplate(ctx, name, name, string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	parsedCache.Add(name, parsedCacheEntry{tmpl: tmpl, etag: etag})
+	return tmpl, nil
+}
+
+// rawByteCache is a bounded, name+ETag-keyed byte cache for raw template
+// source, the inner tier below parsedCache.
+type rawByteCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	order    []string
+	entries  map[string][]byte
+}
+
+func newRawByteCache(maxBytes int) *rawByteCache {
+	return &rawByteCache{maxBytes: maxBytes, entries: map[string][]byte{}}
+}
+
+func rawCacheKey(name, etag string) string {
+	return name + "\x00" + etag
+}
+
+func (c *rawByteCache) get(name, etag string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.entries[rawCacheKey(name, etag)]
+	return data, ok
+}
+
+func (c *rawByteCache) set(name, etag string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := rawCacheKey(name, etag)
+	if _, exists := c.entries[key]; exists {
+		return
+	}
+
+	for c.size+len(data) > c.maxBytes && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.entries[oldest])
+		delete(c.entries, oldest)
+	}
+
+	c.entries[key] = data
+	c.order = append(c.order, key)
+	c.size += len(data)
+}
+
+func (c *rawByteCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := name + "\x00"
+	remaining := c.order[:0]
+	for _, key := range c.order {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			c.size -= len(c.entries[key])
+			delete(c.entries, key)
+			continue
+		}
+		remaining = append(remaining, key)
+	}
+	c.order = remaining
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+)
+
+// resolveTemplate parses src as rootName, then recursively resolves every
+// {{template "x"}} reference it makes into one bundle via AddParseTree.
+// rootCacheKey is the depIndex/cache key for the root template: plain
+// rootName for a template, partialsPrefix+rootName for a partial, since
+// those are the same namespace the two stores otherwise share names in.
+// Cache invalidation is depIndex's job, not this function's.
+func resolveTemplate(ctx context.Context, rootCacheKey, rootName, src string) (*template.Template, error) {
+	bundle := template.New(rootName)
+	visiting := map[string]bool{rootName: true}
+	resolved := map[string]string{rootName: rootCacheKey}
+
+	if err := addIncludes(ctx, bundle, rootCacheKey, rootName, src, visiting, resolved); err != nil {
+		return nil, err
+	}
+
+	return bundle.Lookup(rootName), nil
+}
+
+// addIncludes parses src as name and merges it into bundle, then recurses
+// into its dependencies. cacheKey is name's depIndex/cache key (see
+// resolveTemplate); resolved memoizes the cacheKey each bare dep name was
+// fetched under, so a dep reused as a diamond include isn't re-fetched and
+// still records the correct namespaced edge on every occurrence.
+func addIncludes(ctx context.Context, bundle *template.Template, cacheKey, name, src string, visiting map[string]bool, resolved map[string]string) error {
+	parsed, err := template.New(name).Parse(src)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range parsed.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if _, err := bundle.AddParseTree(t.Name(), t.Tree); err != nil {
+			return err
+		}
+	}
+
+	depIndex.clear(cacheKey)
+
+	for _, dep := range templateDependencies(parsed) {
+		depCacheKey, known := resolved[dep]
+		var entryData string
+		if !known {
+			entry, fetchedKey, err := fetchInclude(ctx, dep)
+			if err != nil {
+				return fmt.Errorf("resolving include %q: %w", dep, err)
+			}
+			depCacheKey = fetchedKey
+			resolved[dep] = fetchedKey
+			entryData = string(entry.Data)
+		}
+
+		depIndex.record(cacheKey, depCacheKey)
+
+		if visiting[dep] {
+			return fmt.Errorf("template include cycle detected at %q", dep)
+		}
+		if bundle.Lookup(dep) != nil {
+			continue
+		}
+
+		visiting[dep] = true
+		err := addIncludes(ctx, bundle, depCacheKey, dep, entryData, visiting, resolved)
+		delete(visiting, dep)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchInclude resolves an included name, preferring the partials
+// namespace and falling back to the regular templates namespace, and
+// reports which namespace it found name in as a depIndex/cache key.
+func fetchInclude(ctx context.Context, name string) (*TemplateEntry, string, error) {
+	entry, err := partialsStore.Get(ctx, name)
+	if err == nil {
+		return entry, partialsPrefix + name, nil
+	}
+	if err != ErrNotFound {
+		return nil, "", err
+	}
+
+	entry, err = store.Get(ctx, name)
+	return entry, name, err
+}
+
+// templateDependencies returns the distinct names referenced by
+// {{template "name"}} actions anywhere in tmpl's associated template set.
+func templateDependencies(tmpl *template.Template) []string {
+	seen := map[string]bool{}
+	var names []string
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.TemplateNode:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				names = append(names, n.Name)
+			}
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	return names
+}
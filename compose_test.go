@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type fakeTemplateStore struct {
+	entries map[string][]byte
+}
+
+func newFakeTemplateStore() *fakeTemplateStore {
+	return &fakeTemplateStore{entries: map[string][]byte{}}
+}
+
+func (s *fakeTemplateStore) Put(ctx context.Context, name string, data []byte) error {
+	s.entries[name] = data
+	return nil
+}
+
+func (s *fakeTemplateStore) Get(ctx context.Context, name string) (*TemplateEntry, error) {
+	data, ok := s.entries[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &TemplateEntry{Name: name, Data: data, ETag: name}, nil
+}
+
+func (s *fakeTemplateStore) Head(ctx context.Context, name string) (*TemplateAttributes, error) {
+	if _, ok := s.entries[name]; !ok {
+		return nil, ErrNotFound
+	}
+	return &TemplateAttributes{ETag: name}, nil
+}
+
+func (s *fakeTemplateStore) Delete(ctx context.Context, name string) error {
+	if _, ok := s.entries[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, name)
+	return nil
+}
+
+func (s *fakeTemplateStore) List(ctx context.Context, prefix string, limit int, pageToken string) (*TemplateListPage, error) {
+	return &TemplateListPage{}, nil
+}
+
+// withFakeStores swaps the package-level store/partialsStore for in-memory
+// fakes for the duration of a test, restoring them on cleanup.
+func withFakeStores(t *testing.T) {
+	t.Helper()
+	origStore, origPartials := store, partialsStore
+	store = newFakeTemplateStore()
+	partialsStore = newFakeTemplateStore()
+	t.Cleanup(func() {
+		store = origStore
+		partialsStore = origPartials
+	})
+}
+
+func TestResolveTemplateDetectsDirectCycle(t *testing.T) {
+	withFakeStores(t)
+	ctx := context.Background()
+
+	if err := partialsStore.Put(ctx, "b", []byte(`{{template "a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := resolveTemplate(ctx, "a", "a", `{{template "b"}}`)
+	if err == nil {
+		t.Fatal("expected a cycle error, got nil")
+	}
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestResolveTemplateDetectsChainCycle(t *testing.T) {
+	withFakeStores(t)
+	ctx := context.Background()
+
+	if err := partialsStore.Put(ctx, "b", []byte(`{{template "c"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := partialsStore.Put(ctx, "c", []byte(`{{template "a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := resolveTemplate(ctx, "a", "a", `{{template "b"}}`)
+	if err == nil {
+		t.Fatal("expected a cycle error, got nil")
+	}
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestResolveTemplateAllowsDiamondInclude(t *testing.T) {
+	withFakeStores(t)
+	ctx := context.Background()
+
+	if err := partialsStore.Put(ctx, "shared", []byte(`shared`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := partialsStore.Put(ctx, "b", []byte(`{{template "shared"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := partialsStore.Put(ctx, "c", []byte(`{{template "shared"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolveTemplate(ctx, "a", "a", `{{template "b"}}{{template "c"}}`); err != nil {
+		t.Fatalf("expected diamond include to resolve, got %v", err)
+	}
+}
+
+func TestAddIncludesNamespacesDepIndexKeys(t *testing.T) {
+	withFakeStores(t)
+	ctx := context.Background()
+
+	if err := partialsStore.Put(ctx, "shared", []byte(`partial`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "shared", []byte(`template`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolveTemplate(ctx, "root", "root", `{{template "shared"}}`); err != nil {
+		t.Fatalf("expected include to resolve, got %v", err)
+	}
+
+	depIndex.mu.Lock()
+	_, dependsOnPartial := depIndex.dependents[partialsPrefix+"shared"]["root"]
+	_, dependsOnTemplate := depIndex.dependents["shared"]["root"]
+	depIndex.mu.Unlock()
+
+	if !dependsOnPartial {
+		t.Fatal("expected root to be recorded against the partials-namespaced key, since {{template}} prefers the partials namespace")
+	}
+	if dependsOnTemplate {
+		t.Fatal("root should not be recorded against the bare template-namespace key sharing the same name as the partial it actually included")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// envInt reads name from the environment as an int, falling back to def if
+// it is unset or not a valid integer.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+
+	return n
+}
+
+// envFloat reads name from the environment as a float64, falling back to
+// def if it is unset or not a valid number.
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+
+	return f
+}
+
+// envDuration reads name from the environment as a time.Duration (e.g.
+// "100ms", "30s"), falling back to def if it is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,67 @@
+package main
+
+import "sync"
+
+// dependencyIndex is a reverse {{template}} include index: child name to
+// the set of templates whose bundles include it, directly or transitively.
+type dependencyIndex struct {
+	mu         sync.Mutex
+	dependents map[string]map[string]bool // child name -> set of parent names
+}
+
+var depIndex = &dependencyIndex{dependents: map[string]map[string]bool{}}
+
+// record notes that parent's bundle directly includes child.
+func (d *dependencyIndex) record(parent, child string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	set, ok := d.dependents[child]
+	if !ok {
+		set = map[string]bool{}
+		d.dependents[child] = set
+	}
+	set[parent] = true
+}
+
+// clear forgets every edge recorded with parent as the including template.
+func (d *dependencyIndex) clear(parent string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for child, parents := range d.dependents {
+		delete(parents, parent)
+		if len(parents) == 0 {
+			delete(d.dependents, child)
+		}
+	}
+}
+
+// invalidate evicts name, plus every template (transitively) whose bundle
+// includes it, from the parsed and raw caches.
+func (d *dependencyIndex) invalidate(name string) {
+	d.mu.Lock()
+	queue := []string{name}
+	seen := map[string]bool{name: true}
+	var affected []string
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for parent := range d.dependents[n] {
+			if seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			affected = append(affected, parent)
+			queue = append(queue, parent)
+		}
+	}
+	d.mu.Unlock()
+
+	parsedCache.Remove(name)
+	rawCache.delete(name)
+	for _, parent := range affected {
+		parsedCache.Remove(parent)
+		rawCache.delete(parent)
+	}
+}
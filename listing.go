@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listTemplatesHandler serves GET /templates, paginated via ?limit=
+// (default 100, max 1000), ?prefix= and an opaque ?pageToken=.
+func listTemplatesHandler(c *gin.Context) {
+	limit := defaultListLimit
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	page, err := store.List(c, c.Query("prefix"), limit, c.Query("pageToken"))
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	c.JSON(200, page)
+}
+
+// headTemplateHandler serves HEAD /templates/:templatename: ETag,
+// Last-Modified and Content-Length as response headers, no body.
+func headTemplateHandler(c *gin.Context) {
+	templateName := c.Param("templatename")
+
+	attrs, err := store.Head(c, templateName)
+	if err == ErrNotFound {
+		c.AbortWithStatus(404)
+		return
+	}
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	c.Header("ETag", attrs.ETag)
+	c.Header("Last-Modified", attrs.ModTime.UTC().Format(http.TimeFormat))
+	c.Header("Content-Length", strconv.FormatInt(attrs.Size, 10))
+	c.Status(200)
+}
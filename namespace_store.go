@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// namespacedStore scopes every name with a fixed prefix before delegating
+// to an underlying TemplateStore, used to carve the partials namespace out
+// of the same backing store as the regular templates.
+type namespacedStore struct {
+	inner  TemplateStore
+	prefix string
+}
+
+func (s *namespacedStore) Put(ctx context.Context, name string, data []byte) error {
+	return s.inner.Put(ctx, s.prefix+name, data)
+}
+
+func (s *namespacedStore) Get(ctx context.Context, name string) (*TemplateEntry, error) {
+	entry, err := s.inner.Get(ctx, s.prefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Name = name
+	return entry, nil
+}
+
+func (s *namespacedStore) Head(ctx context.Context, name string) (*TemplateAttributes, error) {
+	return s.inner.Head(ctx, s.prefix+name)
+}
+
+func (s *namespacedStore) Delete(ctx context.Context, name string) error {
+	return s.inner.Delete(ctx, s.prefix+name)
+}
+
+func (s *namespacedStore) List(ctx context.Context, prefix string, limit int, pageToken string) (*TemplateListPage, error) {
+	page, err := s.inner.List(ctx, s.prefix+prefix, limit, pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range page.Entries {
+		page.Entries[i].Name = strings.TrimPrefix(page.Entries[i].Name, s.prefix)
+	}
+	return page, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"gocloud.dev/gcerrors"
+)
+
+var (
+	retryInitialBackoff = envDuration("TEMPLATE_RETRY_INITIAL_BACKOFF", 100*time.Millisecond)
+	retryMaxBackoff     = envDuration("TEMPLATE_RETRY_MAX_BACKOFF", 30*time.Second)
+	retryFactor         = envFloat("TEMPLATE_RETRY_FACTOR", 2)
+	retryMaxAttempts    = envInt("TEMPLATE_RETRY_MAX_ATTEMPTS", 5)
+)
+
+// isRetryableCode reports whether a gocloud.dev error code represents a
+// transient failure worth retrying.
+func isRetryableCode(code gcerrors.ErrorCode) bool {
+	switch code {
+	case gcerrors.Unknown, gcerrors.DeadlineExceeded, gcerrors.ResourceExhausted, gcerrors.Internal:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryCoder lets an error report its own gcerrors.ErrorCode, so tests can
+// supply a fake error without depending on gocloud.dev's unexported error
+// type to construct one.
+type retryCoder interface {
+	Code() gcerrors.ErrorCode
+}
+
+// isRetryable reports whether err is worth a retry: never for ErrNotFound,
+// otherwise by the gcerrors code it (or a retryCoder it implements) carries.
+func isRetryable(err error) bool {
+	if err == ErrNotFound {
+		return false
+	}
+	if rc, ok := err.(retryCoder); ok {
+		return isRetryableCode(rc.Code())
+	}
+	return isRetryableCode(gcerrors.Code(err))
+}
+
+// withRetry runs op up to retryMaxAttempts times, retrying only errors
+// isRetryableCode accepts, with jittered exponential backoff starting at
+// retryInitialBackoff and capped at retryMaxBackoff.
+func withRetry(ctx context.Context, op func(attempt int) error) error {
+	backoff := retryInitialBackoff
+
+	var err error
+	for attempt := 1; attempt <= retryMaxAttempts; attempt++ {
+		err = op(attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		if attempt == retryMaxAttempts {
+			break
+		}
+
+		sleep := time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff = time.Duration(float64(backoff) * retryFactor)
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gocloud.dev/gcerrors"
+)
+
+// fakeCodeError is a retryCoder test double: it reports a gcerrors.ErrorCode
+// without needing gocloud.dev's unexported error type to construct one.
+type fakeCodeError struct {
+	code gcerrors.ErrorCode
+}
+
+func (e fakeCodeError) Error() string { return "injected" }
+
+func (e fakeCodeError) Code() gcerrors.ErrorCode { return e.code }
+
+// withFastRetryTimings shrinks the backoff window for the duration of a
+// test so the retry matrix below doesn't spend real wall-clock time
+// sleeping between attempts.
+func withFastRetryTimings(t *testing.T) {
+	t.Helper()
+	origInitial, origMax := retryInitialBackoff, retryMaxBackoff
+	retryInitialBackoff = time.Millisecond
+	retryMaxBackoff = 2 * time.Millisecond
+	t.Cleanup(func() {
+		retryInitialBackoff, retryMaxBackoff = origInitial, origMax
+	})
+}
+
+func TestWithRetryCodeMatrix(t *testing.T) {
+	withFastRetryTimings(t)
+
+	cases := []struct {
+		name         string
+		code         gcerrors.ErrorCode
+		failFor      int // attempts 1..failFor return the injected error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "unknown retries then succeeds", code: gcerrors.Unknown, failFor: 2, wantAttempts: 3},
+		{name: "deadline exceeded retries then succeeds", code: gcerrors.DeadlineExceeded, failFor: 1, wantAttempts: 2},
+		{name: "resource exhausted retries then succeeds", code: gcerrors.ResourceExhausted, failFor: 1, wantAttempts: 2},
+		{name: "internal retries then succeeds", code: gcerrors.Internal, failFor: 1, wantAttempts: 2},
+		{name: "not found never retries", code: gcerrors.NotFound, failFor: 10, wantAttempts: 1, wantErr: true},
+		{name: "already exists never retries", code: gcerrors.AlreadyExists, failFor: 10, wantAttempts: 1, wantErr: true},
+		{name: "permission denied never retries", code: gcerrors.PermissionDenied, failFor: 10, wantAttempts: 1, wantErr: true},
+		{name: "invalid argument never retries", code: gcerrors.InvalidArgument, failFor: 10, wantAttempts: 1, wantErr: true},
+		{name: "exhausts all attempts and fails", code: gcerrors.Internal, failFor: 10, wantAttempts: retryMaxAttempts, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			err := withRetry(context.Background(), func(attempt int) error {
+				attempts++
+				if attempt <= tc.failFor {
+					return fakeCodeError{code: tc.code}
+				}
+				return nil
+			})
+
+			if attempts != tc.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tc.wantAttempts)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithRetryNeverRetriesErrNotFound(t *testing.T) {
+	withFastRetryTimings(t)
+
+	attempts := 0
+	err := withRetry(context.Background(), func(attempt int) error {
+		attempts++
+		return ErrNotFound
+	})
+
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWithRetryStopsOnContextCancellation(t *testing.T) {
+	withFastRetryTimings(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := withRetry(ctx, func(attempt int) error {
+		attempts++
+		return fakeCodeError{code: gcerrors.Internal}
+	})
+
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
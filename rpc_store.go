@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/rpc"
+)
+
+// RPCTemplateStore implements TemplateStore by forwarding every call over
+// net/rpc to a TemplateStoreService hosted by another template-api
+// instance (see newTemplateStore).
+type RPCTemplateStore struct {
+	client *rpc.Client
+}
+
+// DialRPCTemplateStore dials the template-api instance hosting the shared
+// store at addr (host:port).
+func DialRPCTemplateStore(addr string) (*RPCTemplateStore, error) {
+	client, err := rpc.DialHTTP("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RPCTemplateStore{client: client}, nil
+}
+
+type rpcPutArgs struct {
+	Name string
+	Data []byte
+}
+
+type rpcNameArgs struct {
+	Name string
+}
+
+type rpcGetReply struct {
+	Found bool
+	Entry TemplateEntry
+}
+
+type rpcListArgs struct {
+	Prefix    string
+	Limit     int
+	PageToken string
+}
+
+type rpcListReply struct {
+	Page TemplateListPage
+}
+
+type rpcHeadReply struct {
+	Found bool
+	Attrs TemplateAttributes
+}
+
+type rpcDeleteReply struct {
+	Found bool
+}
+
+// call wraps client.Go so an in-flight RPC is abandoned as soon as ctx is
+// done, instead of client.Call's unconditional block.
+func (s *RPCTemplateStore) call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	call := s.client.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *RPCTemplateStore) Put(ctx context.Context, name string, data []byte) error {
+	return s.call(ctx, "TemplateStoreService.Put", &rpcPutArgs{Name: name, Data: data}, &struct{}{})
+}
+
+func (s *RPCTemplateStore) Get(ctx context.Context, name string) (*TemplateEntry, error) {
+	reply := rpcGetReply{}
+	if err := s.call(ctx, "TemplateStoreService.Get", &rpcNameArgs{Name: name}, &reply); err != nil {
+		return nil, err
+	}
+	if !reply.Found {
+		return nil, ErrNotFound
+	}
+
+	return &reply.Entry, nil
+}
+
+func (s *RPCTemplateStore) Head(ctx context.Context, name string) (*TemplateAttributes, error) {
+	reply := rpcHeadReply{}
+	if err := s.call(ctx, "TemplateStoreService.Head", &rpcNameArgs{Name: name}, &reply); err != nil {
+		return nil, err
+	}
+	if !reply.Found {
+		return nil, ErrNotFound
+	}
+
+	return &reply.Attrs, nil
+}
+
+func (s *RPCTemplateStore) Delete(ctx context.Context, name string) error {
+	reply := rpcDeleteReply{}
+	if err := s.call(ctx, "TemplateStoreService.Delete", &rpcNameArgs{Name: name}, &reply); err != nil {
+		return err
+	}
+	if !reply.Found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *RPCTemplateStore) List(ctx context.Context, prefix string, limit int, pageToken string) (*TemplateListPage, error) {
+	reply := rpcListReply{}
+	args := &rpcListArgs{Prefix: prefix, Limit: limit, PageToken: pageToken}
+	if err := s.call(ctx, "TemplateStoreService.List", args, &reply); err != nil {
+		return nil, err
+	}
+
+	return &reply.Page, nil
+}
+
+// TemplateStoreService exposes a backing TemplateStore over net/rpc; see
+// ServeTemplateStoreRPC.
+type TemplateStoreService struct {
+	backing TemplateStore
+}
+
+func NewTemplateStoreService(backing TemplateStore) *TemplateStoreService {
+	return &TemplateStoreService{backing: backing}
+}
+
+func (s *TemplateStoreService) Put(args *rpcPutArgs, reply *struct{}) error {
+	return s.backing.Put(context.Background(), args.Name, args.Data)
+}
+
+func (s *TemplateStoreService) Get(args *rpcNameArgs, reply *rpcGetReply) error {
+	entry, err := s.backing.Get(context.Background(), args.Name)
+	if errors.Is(err, ErrNotFound) {
+		reply.Found = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reply.Found = true
+	reply.Entry = *entry
+	return nil
+}
+
+func (s *TemplateStoreService) Head(args *rpcNameArgs, reply *rpcHeadReply) error {
+	attrs, err := s.backing.Head(context.Background(), args.Name)
+	if errors.Is(err, ErrNotFound) {
+		reply.Found = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reply.Found = true
+	reply.Attrs = *attrs
+	return nil
+}
+
+func (s *TemplateStoreService) Delete(args *rpcNameArgs, reply *rpcDeleteReply) error {
+	err := s.backing.Delete(context.Background(), args.Name)
+	if errors.Is(err, ErrNotFound) {
+		reply.Found = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reply.Found = true
+	return nil
+}
+
+func (s *TemplateStoreService) List(args *rpcListArgs, reply *rpcListReply) error {
+	page, err := s.backing.List(context.Background(), args.Prefix, args.Limit, args.PageToken)
+	if err != nil {
+		return err
+	}
+
+	reply.Page = *page
+	return nil
+}
+
+// ServeTemplateStoreRPC registers backing as a TemplateStoreService and
+// serves it over HTTP-hosted net/rpc at addr. It blocks until the listener
+// fails.
+func ServeTemplateStoreRPC(addr string, backing TemplateStore) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("TemplateStoreService", NewTemplateStoreService(backing)); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, server)
+	return http.ListenAndServe(addr, mux)
+}
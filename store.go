@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by TemplateStore implementations when the
+// requested template does not exist.
+var ErrNotFound = errors.New("template not found")
+
+// TemplateEntry is a stored template's raw source plus its ETag.
+type TemplateEntry struct {
+	Name string
+	Data []byte
+	ETag string
+}
+
+// TemplateAttributes is a stored template's metadata without its body.
+type TemplateAttributes struct {
+	ETag    string
+	Size    int64
+	ModTime time.Time
+}
+
+// TemplateListEntry is one row of a TemplateStore.List result.
+type TemplateListEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+// TemplateListPage is one page of a TemplateStore.List result. NextPageToken
+// is empty once there are no more pages.
+type TemplateListPage struct {
+	Entries       []TemplateListEntry
+	NextPageToken string
+}
+
+// TemplateStore abstracts where template source is kept, so the HTTP layer
+// doesn't care whether it's talking to a blob bucket directly or over RPC.
+type TemplateStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) (*TemplateEntry, error)
+	Head(ctx context.Context, name string) (*TemplateAttributes, error)
+	Delete(ctx context.Context, name string) error
+	// List returns up to limit entries with the given name prefix,
+	// starting after pageToken (empty for the first page).
+	List(ctx context.Context, prefix string, limit int, pageToken string) (*TemplateListPage, error)
+}
+
+// newTemplateStore builds the TemplateStore configured via environment
+// variables. TEMPLATE_STORE_BACKEND defaults to "blob"; set it to "rpc" to
+// dial a shared template-api instance at TEMPLATE_RPC_ADDR instead.
+func newTemplateStore(ctx context.Context) (TemplateStore, error) {
+	switch storeBackend {
+	case "rpc":
+		return DialRPCTemplateStore(rpcAddr)
+	case "", "blob":
+		return NewBlobTemplateStore(ctx, blobBucketURL, blobPrefix)
+	default:
+		return nil, errors.New("unknown TEMPLATE_STORE_BACKEND: " + storeBackend)
+	}
+}